@@ -0,0 +1,181 @@
+package consul
+
+import (
+	"crypto/x509"
+	"time"
+)
+
+// Config is the rendered configuration pushed down Watcher.C every time
+// something in the mesh changes. It is consumed by the haproxy package to
+// regenerate the on-disk configuration file.
+type Config struct {
+	ServiceID   string
+	ServiceName string
+
+	CAsPool *x509.CertPool
+
+	Downstream Downstream
+	Upstreams  []Upstream
+
+	// Intentions is the allow/deny matrix computed from Consul intentions
+	// matching this service as a destination, ordered by precedence.
+	Intentions []IntentionRule
+
+	AccessLog AccessLog
+}
+
+// Downstream describes the local frontend haproxy exposes to accept
+// connections from the mesh and forward them to the service instance
+// running alongside the sidecar.
+type Downstream struct {
+	LocalBindAddress string
+	LocalBindPort    int
+
+	TargetAddress string
+	TargetPort    int
+
+	Protocol          string
+	EnableForwardFor  bool
+	AppNameHeaderName string
+
+	Tracing Tracing
+
+	TLS TLS
+}
+
+// Tracing configures distributed-tracing propagation and span export for
+// the downstream listener, parsed from the envoy_tracing_json/
+// envoy_extensions proxy-defaults keys Consul's Envoy integration uses, or
+// from this module's own "tracing" config block.
+type Tracing struct {
+	Provider         string
+	CollectorAddress string
+	SampleRate       float64
+	TagHeaders       []string
+}
+
+// AccessLog configures how haproxy logs each request, parsed from the
+// "access_logs" proxy-defaults key.
+type AccessLog struct {
+	Format      string
+	Destination string
+	JSON        bool
+}
+
+// TLS carries the certificate material needed to terminate or originate
+// mTLS connections for a given frontend or backend.
+type TLS struct {
+	CAs  [][]byte
+	Cert []byte
+	Key  []byte
+}
+
+// Upstream is a service this sidecar can connect to. For protocol "http"
+// upstreams with an associated discovery chain, Routes describes how
+// requests should be split across Subsets instead of the flat Nodes list.
+type Upstream struct {
+	Name             string
+	LocalBindAddress string
+	LocalBindPort    int
+	Protocol         string
+
+	Nodes []UpstreamNode
+
+	// Routes and Subsets are populated for "http" protocol upstreams that
+	// have a compiled discovery chain (service-router/service-splitter/
+	// service-resolver config entries). When Routes is empty, Nodes should
+	// be used as a plain list of backend instances.
+	Routes  []Route
+	Subsets []Subset
+
+	TLS TLS
+}
+
+// UpstreamNode is a single healthy instance of an upstream service.
+type UpstreamNode struct {
+	Name    string
+	Address string
+	Port    int
+	Weight  int
+}
+
+// Route is a single entry of an upstream's discovery chain routing table,
+// compiled from a service-router config entry. Routes are evaluated in
+// order; the first one whose Match matches the request wins.
+type Route struct {
+	Match       RouteMatch
+	Destination RouteDestination
+}
+
+// RouteMatch describes the HTTP criteria a request must meet for a Route
+// to apply. Only one of PathExact, PathPrefix or PathRegex is set.
+type RouteMatch struct {
+	PathExact  string
+	PathPrefix string
+	PathRegex  string
+	Methods    []string
+	Headers    []RouteHeaderMatch
+}
+
+// RouteHeaderMatch is a single header match criterion. Only one of Exact,
+// Prefix, Regex or Present is set.
+type RouteHeaderMatch struct {
+	Name    string
+	Exact   string
+	Prefix  string
+	Regex   string
+	Present bool
+	Invert  bool
+}
+
+// RouteDestination describes where a matched request should be sent and
+// under which timeout/retry policy.
+type RouteDestination struct {
+	Subset string
+
+	ConnectTimeout        time.Duration
+	NumRetries            int
+	RetryOnConnectFailure bool
+	RetryOnStatusCodes    []uint32
+}
+
+// Subset is a named, weighted group of upstream instances, as produced by
+// a service-splitter or a service-resolver's subset definitions. Each
+// Subset maps to its own HAProxy backend.
+type Subset struct {
+	Name   string
+	Weight float32
+	Nodes  []UpstreamNode
+}
+
+// IntentionRule is a single compiled intention allowing or denying
+// connections from a SPIFFE source to this service, optionally narrowed
+// to specific HTTP requests.
+type IntentionRule struct {
+	Action     string // "allow" or "deny"
+	Precedence int
+
+	SourceName string
+	SourcePeer string
+
+	Match []IntentionHTTPMatch
+}
+
+// IntentionHTTPMatch is the L7 match criteria of a single intention
+// permission. An empty value matches every request.
+type IntentionHTTPMatch struct {
+	PathExact  string
+	PathPrefix string
+	PathRegex  string
+	Methods    []string
+	Headers    []RouteHeaderMatch
+}
+
+// Logger is the logging interface the consul package relies on. It is
+// satisfied by *logrus.Entry amongst others.
+type Logger interface {
+	Debugf(f string, args ...interface{})
+	Infof(f string, args ...interface{})
+	Warnf(f string, args ...interface{})
+	Errorf(f string, args ...interface{})
+}
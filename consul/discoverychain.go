@@ -0,0 +1,224 @@
+package consul
+
+import (
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// startDiscoveryChain watches the compiled discovery chain for an "http"
+// protocol upstream (i.e. service-router/service-splitter/service-resolver
+// config entries) and keeps u.Routes/u.Subsets in sync, re-emitting the
+// config whenever a referenced config entry changes.
+func (w *Watcher) startDiscoveryChain(up api.Upstream, name string, u *upstream) {
+	w.log.Infof("consul: watching discovery chain for upstream %s", up.DestinationName)
+
+	go func() {
+		index := uint64(0)
+		first := true
+		for {
+			if u.done {
+				return
+			}
+
+			chainRsp, meta, err := w.consul.DiscoveryChain().Get(up.DestinationName, &api.DiscoveryChainOptions{
+				EvaluateInDatacenter: up.Datacenter,
+			}, &api.QueryOptions{
+				WaitTime:  10 * time.Minute,
+				WaitIndex: index,
+			})
+			if err != nil {
+				w.log.Errorf("consul: error fetching discovery chain for upstream %s: %s", up.DestinationName, err)
+				time.Sleep(errorWaitTime)
+				index = 0
+				continue
+			}
+
+			changed := index != meta.LastIndex
+			index = meta.LastIndex
+
+			if changed {
+				routes, targets, weights := compileDiscoveryChain(chainRsp.Chain)
+
+				w.lock.Lock()
+				u.Routes = routes
+				u.subsetWeights = weights
+				w.lock.Unlock()
+
+				w.watchChainTargets(up, name, u, targets)
+
+				if !first {
+					w.emitRoute(name, routes)
+				}
+				w.notifyChanged()
+			}
+
+			first = false
+		}
+	}()
+}
+
+// compileDiscoveryChain walks the chain's node graph starting at its root
+// and flattens it into an ordered route table plus the set of distinct
+// targets (subsets) those routes can land on.
+func compileDiscoveryChain(chain *api.CompiledDiscoveryChain) ([]Route, map[string]*api.DiscoveryTarget, map[string]float32) {
+	if chain == nil {
+		return nil, nil, nil
+	}
+
+	var routes []Route
+	targets := map[string]*api.DiscoveryTarget{}
+	weights := map[string]float32{}
+
+	var walk func(nodeName string, weight float32)
+	walk = func(nodeName string, weight float32) {
+		node, ok := chain.Nodes[nodeName]
+		if !ok || node == nil {
+			return
+		}
+
+		switch node.Type {
+		case api.DiscoveryGraphNodeTypeRouter:
+			for _, r := range node.Routes {
+				routes = append(routes, Route{
+					Match:       compileRouteMatch(r.Definition.Match),
+					Destination: compileRouteDestination(r.Definition.Destination),
+				})
+				walk(r.NextNode, 1)
+			}
+		case api.DiscoveryGraphNodeTypeSplitter:
+			for _, s := range node.Splits {
+				walk(s.NextNode, s.Weight)
+			}
+		case api.DiscoveryGraphNodeTypeResolver:
+			if node.Resolver == nil {
+				return
+			}
+			if t, ok := chain.Targets[node.Resolver.Target]; ok {
+				targets[node.Resolver.Target] = t
+				// weights is keyed by ServiceSubset, not the target ID,
+				// since that's how u.subsets (and genCfg's lookup of
+				// up.subsetWeights) key it too.
+				weights[t.ServiceSubset] += weight
+			}
+		}
+	}
+
+	walk(chain.StartNode, 1)
+
+	return routes, targets, weights
+}
+
+func compileRouteMatch(m *api.ServiceRouteMatch) RouteMatch {
+	if m == nil || m.HTTP == nil {
+		return RouteMatch{}
+	}
+
+	rm := RouteMatch{
+		PathExact:  m.HTTP.PathExact,
+		PathPrefix: m.HTTP.PathPrefix,
+		PathRegex:  m.HTTP.PathRegex,
+		Methods:    m.HTTP.Methods,
+	}
+
+	for _, h := range m.HTTP.Header {
+		rm.Headers = append(rm.Headers, RouteHeaderMatch{
+			Name:    h.Name,
+			Exact:   h.Exact,
+			Prefix:  h.Prefix,
+			Regex:   h.Regex,
+			Present: h.Present,
+			Invert:  h.Invert,
+		})
+	}
+
+	return rm
+}
+
+func compileRouteDestination(d *api.ServiceRouteDestination) RouteDestination {
+	if d == nil {
+		return RouteDestination{}
+	}
+
+	return RouteDestination{
+		Subset:                d.ServiceSubset,
+		ConnectTimeout:        d.RequestTimeout,
+		NumRetries:            int(d.NumRetries),
+		RetryOnConnectFailure: d.RetryOnConnectFailure,
+		RetryOnStatusCodes:    d.RetryOnStatusCodes,
+	}
+}
+
+// watchChainTargets makes sure a node-watching goroutine is running for
+// every distinct discovery-chain target so each subset's Nodes stay fresh,
+// stopping watches for targets that disappeared from the chain.
+func (w *Watcher) watchChainTargets(up api.Upstream, name string, u *upstream, targets map[string]*api.DiscoveryTarget) {
+	w.lock.Lock()
+	if u.subsetWatches == nil {
+		u.subsetWatches = map[string]*subsetWatch{}
+	}
+
+	for id, target := range targets {
+		if _, ok := u.subsetWatches[id]; ok {
+			continue
+		}
+		sw := &subsetWatch{}
+		u.subsetWatches[id] = sw
+		go w.watchSubset(up, name, u, id, target, sw)
+	}
+
+	for id, sw := range u.subsetWatches {
+		if _, ok := targets[id]; !ok {
+			sw.done = true
+			delete(u.subsetWatches, id)
+		}
+	}
+	w.lock.Unlock()
+}
+
+type subsetWatch struct {
+	done bool
+}
+
+func (w *Watcher) watchSubset(up api.Upstream, name string, u *upstream, targetID string, target *api.DiscoveryTarget, sw *subsetWatch) {
+	w.log.Infof("consul: watching subset %s for upstream %s", targetID, up.DestinationName)
+
+	index := uint64(0)
+	for {
+		if sw.done || u.done {
+			return
+		}
+
+		nodes, meta, err := w.consul.Health().Connect(target.Service, target.Filter, true, &api.QueryOptions{
+			Datacenter: target.Datacenter,
+			Namespace:  target.Namespace,
+			WaitTime:   10 * time.Minute,
+			WaitIndex:  index,
+		})
+		if err != nil {
+			w.log.Errorf("consul: error fetching subset %s for upstream %s: %s", targetID, up.DestinationName, err)
+			time.Sleep(errorWaitTime)
+			index = 0
+			continue
+		}
+
+		changed := index != meta.LastIndex
+		index = meta.LastIndex
+
+		if changed {
+			w.lock.Lock()
+			u.subsets = setSubsetNodes(u.subsets, target.ServiceSubset, nodes)
+			w.lock.Unlock()
+			w.emitEndpoint(name, target.ServiceSubset, healthyNodes(nodes))
+			w.notifyChanged()
+		}
+	}
+}
+
+func setSubsetNodes(subsets map[string][]*api.ServiceEntry, name string, nodes []*api.ServiceEntry) map[string][]*api.ServiceEntry {
+	if subsets == nil {
+		subsets = map[string][]*api.ServiceEntry{}
+	}
+	subsets[name] = nodes
+	return subsets
+}
@@ -0,0 +1,95 @@
+package consul
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompileDiscoveryChain(t *testing.T) {
+	chain := &api.CompiledDiscoveryChain{
+		StartNode: "router",
+		Nodes: map[string]*api.DiscoveryGraphNode{
+			"router": {
+				Type: api.DiscoveryGraphNodeTypeRouter,
+				Routes: []*api.DiscoveryRoute{
+					{
+						Definition: &api.ServiceRoute{
+							Match: &api.ServiceRouteMatch{
+								HTTP: &api.ServiceRouteHTTPMatch{
+									PathPrefix: "/",
+								},
+							},
+						},
+						NextNode: "splitter",
+					},
+				},
+			},
+			"splitter": {
+				Type: api.DiscoveryGraphNodeTypeSplitter,
+				Splits: []*api.DiscoverySplit{
+					{Weight: 90, NextNode: "resolver-v1"},
+					{Weight: 10, NextNode: "resolver-v2"},
+				},
+			},
+			"resolver-v1": {
+				Type:     api.DiscoveryGraphNodeTypeResolver,
+				Resolver: &api.DiscoveryResolver{Target: "v1.web.default.dc1"},
+			},
+			"resolver-v2": {
+				Type:     api.DiscoveryGraphNodeTypeResolver,
+				Resolver: &api.DiscoveryResolver{Target: "v2.web.default.dc1"},
+			},
+		},
+		Targets: map[string]*api.DiscoveryTarget{
+			"v1.web.default.dc1": {ServiceSubset: "v1"},
+			"v2.web.default.dc1": {ServiceSubset: "v2"},
+		},
+	}
+
+	routes, targets, weights := compileDiscoveryChain(chain)
+
+	require.Len(t, routes, 1)
+	require.Len(t, targets, 2)
+
+	// weights must be keyed by ServiceSubset, matching how u.subsets (and
+	// genCfg's lookup of up.subsetWeights) are keyed - not by the
+	// discovery-chain target ID.
+	require.Equal(t, map[string]float32{"v1": 90, "v2": 10}, weights)
+}
+
+func TestCompileDiscoveryChainNilChain(t *testing.T) {
+	routes, targets, weights := compileDiscoveryChain(nil)
+	require.Nil(t, routes)
+	require.Nil(t, targets)
+	require.Nil(t, weights)
+}
+
+func TestHealthyNodes(t *testing.T) {
+	nodes := []*api.ServiceEntry{
+		{
+			Node:    &api.Node{Node: "node-b", Address: "10.0.0.2"},
+			Service: &api.AgentService{Port: 8080, Weights: api.AgentWeights{Passing: 1, Warning: 1}},
+			Checks:  api.HealthChecks{{Status: api.HealthPassing}},
+		},
+		{
+			Node:    &api.Node{Node: "node-a", Address: "10.0.0.1"},
+			Service: &api.AgentService{Port: 8080, Weights: api.AgentWeights{Passing: 1, Warning: 1}},
+			Checks:  api.HealthChecks{{Status: api.HealthWarning}},
+		},
+		{
+			Node:    &api.Node{Node: "node-c", Address: "10.0.0.3"},
+			Service: &api.AgentService{Port: 8080, Weights: api.AgentWeights{Passing: 1, Warning: 1}},
+			Checks:  api.HealthChecks{{Status: api.HealthCritical}},
+		},
+	}
+
+	got := healthyNodes(nodes)
+
+	// critical instances are dropped, the rest are sorted by node name.
+	require.Equal(t, []UpstreamNode{
+		{Name: "node-a", Address: "10.0.0.1", Port: 8080, Weight: 1},
+		{Name: "node-b", Address: "10.0.0.2", Port: 8080, Weight: 1},
+	}, got)
+}
@@ -0,0 +1,125 @@
+package consul
+
+import (
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// watchIntentions keeps w.intentions in sync with the intentions matching
+// this service as a destination, so the emitted Config always carries an
+// up to date allow/deny matrix. Without this, the sidecar only terminates
+// mTLS and lets through any client with a valid mesh certificate,
+// regardless of what intentions say.
+func (w *Watcher) watchIntentions() {
+	w.log.Debugf("consul: watching intentions for %s", w.serviceName)
+
+	first := true
+	var lastIndex uint64
+	for {
+		if w.serviceName == "" {
+			// the destination service name isn't known until the proxy's
+			// sidecar registration has been fetched at least once.
+			time.Sleep(errorWaitTime)
+			continue
+		}
+
+		matches, meta, err := w.consul.Connect().IntentionMatch(&api.IntentionMatch{
+			By:    api.IntentionMatchDestination,
+			Names: []string{w.serviceName},
+		}, &api.QueryOptions{
+			WaitIndex: lastIndex,
+			WaitTime:  10 * time.Minute,
+		})
+		if err != nil {
+			w.log.Errorf("consul: error fetching intentions for %s: %s", w.serviceName, err)
+			time.Sleep(errorWaitTime)
+			lastIndex = 0
+			continue
+		}
+
+		changed := lastIndex != meta.LastIndex
+		lastIndex = meta.LastIndex
+
+		if changed {
+			w.log.Infof("consul: intentions for %s changed", w.serviceName)
+
+			rules := compileIntentions(matches[w.serviceName])
+
+			w.lock.Lock()
+			w.intentions = rules
+			w.lock.Unlock()
+
+			w.notifyChanged()
+		}
+
+		if first {
+			w.log.Infof("consul: intentions for %s ready", w.serviceName)
+			w.ready.Done()
+			first = false
+		}
+	}
+}
+
+// compileIntentions flattens matched intentions, already returned in
+// precedence order by Consul, into the rule list the haproxy generator
+// consumes. An intention with L7 permissions expands into one rule per
+// permission; one without expands into a single unconditional rule.
+func compileIntentions(matched []*api.Intention) []IntentionRule {
+	var rules []IntentionRule
+
+	for _, ixn := range matched {
+		source := ixn.SourceName
+		if ixn.SourceNS != "" {
+			source = ixn.SourceNS + "/" + source
+		}
+
+		if len(ixn.Permissions) == 0 {
+			rules = append(rules, IntentionRule{
+				Action:     string(ixn.Action),
+				Precedence: ixn.Precedence,
+				SourceName: source,
+				SourcePeer: ixn.SourcePeer,
+			})
+			continue
+		}
+
+		for _, perm := range ixn.Permissions {
+			rules = append(rules, IntentionRule{
+				Action:     string(perm.Action),
+				Precedence: ixn.Precedence,
+				SourceName: source,
+				SourcePeer: ixn.SourcePeer,
+				Match:      []IntentionHTTPMatch{compileIntentionHTTPMatch(perm.HTTP)},
+			})
+		}
+	}
+
+	return rules
+}
+
+func compileIntentionHTTPMatch(m *api.IntentionHTTPPermission) IntentionHTTPMatch {
+	if m == nil {
+		return IntentionHTTPMatch{}
+	}
+
+	im := IntentionHTTPMatch{
+		PathExact:  m.PathExact,
+		PathPrefix: m.PathPrefix,
+		PathRegex:  m.PathRegex,
+		Methods:    m.Methods,
+	}
+
+	for _, h := range m.Header {
+		im.Headers = append(im.Headers, RouteHeaderMatch{
+			Name:    h.Name,
+			Exact:   h.Exact,
+			Prefix:  h.Prefix,
+			Regex:   h.Regex,
+			Present: h.Present,
+			Invert:  h.Invert,
+		})
+	}
+
+	return im
+}
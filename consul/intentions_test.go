@@ -0,0 +1,87 @@
+package consul
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompileIntentionsNoPermissions(t *testing.T) {
+	matched := []*api.Intention{
+		{
+			SourceName: "web",
+			Action:     api.IntentionActionAllow,
+			Precedence: 10,
+		},
+	}
+
+	rules := compileIntentions(matched)
+
+	require.Equal(t, []IntentionRule{
+		{Action: "allow", Precedence: 10, SourceName: "web"},
+	}, rules)
+}
+
+func TestCompileIntentionsWithNamespaceAndPeer(t *testing.T) {
+	matched := []*api.Intention{
+		{
+			SourceName: "web",
+			SourceNS:   "billing",
+			SourcePeer: "dc2",
+			Action:     api.IntentionActionDeny,
+			Precedence: 5,
+		},
+	}
+
+	rules := compileIntentions(matched)
+
+	require.Equal(t, []IntentionRule{
+		{Action: "deny", Precedence: 5, SourceName: "billing/web", SourcePeer: "dc2"},
+	}, rules)
+}
+
+func TestCompileIntentionsWithPermissions(t *testing.T) {
+	matched := []*api.Intention{
+		{
+			SourceName: "web",
+			Precedence: 1,
+			Permissions: []*api.IntentionPermission{
+				{
+					Action: api.IntentionActionAllow,
+					HTTP: &api.IntentionHTTPPermission{
+						PathPrefix: "/api",
+						Methods:    []string{"GET"},
+					},
+				},
+				{
+					Action: api.IntentionActionDeny,
+					HTTP: &api.IntentionHTTPPermission{
+						PathExact: "/admin",
+					},
+				},
+			},
+		},
+	}
+
+	rules := compileIntentions(matched)
+
+	require.Equal(t, []IntentionRule{
+		{
+			Action:     "allow",
+			Precedence: 1,
+			SourceName: "web",
+			Match: []IntentionHTTPMatch{
+				{PathPrefix: "/api", Methods: []string{"GET"}},
+			},
+		},
+		{
+			Action:     "deny",
+			Precedence: 1,
+			SourceName: "web",
+			Match: []IntentionHTTPMatch{
+				{PathExact: "/admin"},
+			},
+		},
+	}, rules)
+}
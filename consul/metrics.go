@@ -0,0 +1,62 @@
+package consul
+
+// Metrics receives counters/gauges about the watcher's operation. It is
+// satisfied by *metrics.Collector; nil is a valid Watcher.metrics (all
+// calls below go through this file's nil-safe helpers) so wiring it up is
+// opt-in.
+type Metrics interface {
+	IncConfigReload()
+	SetUpstreamNodes(upstream string, n int)
+	SetLeafCertExpiry(seconds float64)
+	IncCARootRotation()
+	IncError(subsystem string)
+}
+
+// SetMetrics wires a Metrics sink into the watcher. It must be called
+// before Run.
+func (w *Watcher) SetMetrics(m Metrics) {
+	w.metrics = m
+}
+
+// LeafCert returns the current leaf certificate and key, for callers that
+// want to reuse it (e.g. to protect the prometheus endpoint) instead of
+// requiring a dedicated certificate.
+func (w *Watcher) LeafCert() (cert, key []byte) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.leaf == nil {
+		return nil, nil
+	}
+	return w.leaf.Cert, w.leaf.Key
+}
+
+func (w *Watcher) incConfigReload() {
+	if w.metrics != nil {
+		w.metrics.IncConfigReload()
+	}
+}
+
+func (w *Watcher) setUpstreamNodes(upstream string, n int) {
+	if w.metrics != nil {
+		w.metrics.SetUpstreamNodes(upstream, n)
+	}
+}
+
+func (w *Watcher) setLeafCertExpiry(seconds float64) {
+	if w.metrics != nil {
+		w.metrics.SetLeafCertExpiry(seconds)
+	}
+}
+
+func (w *Watcher) incCARootRotation() {
+	if w.metrics != nil {
+		w.metrics.IncCARootRotation()
+	}
+}
+
+func (w *Watcher) incError(subsystem string) {
+	if w.metrics != nil {
+		w.metrics.IncError(subsystem)
+	}
+}
@@ -0,0 +1,156 @@
+package consul
+
+import "encoding/json"
+
+// parseTracingConfig extracts distributed-tracing settings from a
+// service's proxy config, giving precedence to this module's own
+// "tracing" block over the Envoy-oriented envoy_tracing_json/
+// envoy_extensions keys Consul also understands. This gives the haproxy
+// sidecar feature parity with the tracing knobs Consul exposes for Envoy.
+func parseTracingConfig(cfg map[string]interface{}) Tracing {
+	var t Tracing
+
+	if tr, ok := cfg["tracing"].(map[string]interface{}); ok {
+		if p, ok := tr["provider"].(string); ok {
+			t.Provider = p
+		}
+		if a, ok := tr["collector_address"].(string); ok {
+			t.CollectorAddress = a
+		}
+		if r, ok := tr["sample_rate"].(float64); ok {
+			t.SampleRate = r
+		}
+		if headers, ok := tr["tag_headers"].([]interface{}); ok {
+			for _, h := range headers {
+				if s, ok := h.(string); ok {
+					t.TagHeaders = append(t.TagHeaders, s)
+				}
+			}
+		}
+	}
+
+	if t.Provider == "" {
+		if raw, ok := cfg["envoy_tracing_json"].(string); ok {
+			parseEnvoyTracingJSON(raw, &t)
+		}
+	}
+
+	if t.Provider == "" {
+		if extensions, ok := cfg["envoy_extensions"].([]interface{}); ok {
+			parseEnvoyExtensions(extensions, &t)
+		}
+	}
+
+	return t
+}
+
+func parseEnvoyTracingJSON(raw string, t *Tracing) {
+	var doc struct {
+		HTTP struct {
+			Name   string `json:"name"`
+			Config struct {
+				CollectorCluster  string `json:"collector_cluster"`
+				CollectorEndpoint string `json:"collector_endpoint"`
+			} `json:"config"`
+		} `json:"http"`
+	}
+
+	if err := json.Unmarshal([]byte(raw), &doc); err != nil {
+		return
+	}
+
+	t.Provider = doc.HTTP.Name
+	// CollectorCluster names the Envoy cluster traces are sent to (e.g.
+	// "jaeger-collector.consul:9411"); CollectorEndpoint is just the HTTP
+	// path on it (e.g. "/api/v2/spans") and isn't part of the address.
+	t.CollectorAddress = doc.HTTP.Config.CollectorCluster
+}
+
+// parseEnvoyExtensions takes the first entry of the envoy_extensions list
+// that carries an arguments.config map as the tracing extension, whatever
+// its name, and extracts its provider name and collector address from it.
+func parseEnvoyExtensions(extensions []interface{}, t *Tracing) {
+	for _, e := range extensions {
+		ext, ok := e.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		name, _ := ext["name"].(string)
+		args, ok := ext["arguments"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		config, ok := args["config"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		t.Provider = name
+		if a, ok := config["collector_address"].(string); ok {
+			t.CollectorAddress = a
+		}
+		return
+	}
+}
+
+// PrometheusConfig is the metrics endpoint configuration read from
+// proxy-defaults, mirroring the -prometheus-ca-file/-prometheus-cert-file
+// flags Consul added to "consul connect envoy".
+type PrometheusConfig struct {
+	BindAddr string
+	CAFile   string
+	CertFile string
+	KeyFile  string
+}
+
+// PrometheusConfig returns the metrics endpoint configuration read from
+// the service's proxy-defaults.
+func (w *Watcher) PrometheusConfig() PrometheusConfig {
+	return w.prometheus
+}
+
+func parsePrometheusConfig(cfg map[string]interface{}) PrometheusConfig {
+	var pc PrometheusConfig
+
+	if a, ok := cfg["prometheus_bind_addr"].(string); ok {
+		pc.BindAddr = a
+	}
+	if f, ok := cfg["prometheus_ca_file"].(string); ok {
+		pc.CAFile = f
+	}
+	if f, ok := cfg["prometheus_cert_file"].(string); ok {
+		pc.CertFile = f
+	}
+	if f, ok := cfg["prometheus_key_file"].(string); ok {
+		pc.KeyFile = f
+	}
+
+	return pc
+}
+
+// parseAccessLogConfig extracts haproxy log/capture settings from the
+// "access_logs" proxy config key.
+func parseAccessLogConfig(cfg map[string]interface{}) AccessLog {
+	var al AccessLog
+
+	raw, ok := cfg["access_logs"].(map[string]interface{})
+	if !ok {
+		return al
+	}
+
+	if f, ok := raw["format"].(string); ok {
+		al.Format = f
+	}
+	if d, ok := raw["path"].(string); ok {
+		al.Destination = d
+	} else if d, ok := raw["syslog_target"].(string); ok {
+		al.Destination = d
+	}
+	if j, ok := raw["json_format"].(bool); ok {
+		al.JSON = j
+	}
+
+	return al
+}
@@ -0,0 +1,98 @@
+package consul
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTracingConfigOwnBlock(t *testing.T) {
+	cfg := map[string]interface{}{
+		"tracing": map[string]interface{}{
+			"provider":          "zipkin",
+			"collector_address": "zipkin.consul:9411",
+			"sample_rate":       0.5,
+			"tag_headers":       []interface{}{"x-request-id"},
+		},
+	}
+
+	require.Equal(t, Tracing{
+		Provider:         "zipkin",
+		CollectorAddress: "zipkin.consul:9411",
+		SampleRate:       0.5,
+		TagHeaders:       []string{"x-request-id"},
+	}, parseTracingConfig(cfg))
+}
+
+func TestParseTracingConfigEnvoyTracingJSONFallback(t *testing.T) {
+	cfg := map[string]interface{}{
+		"envoy_tracing_json": `{
+			"http": {
+				"name": "envoy.tracers.zipkin",
+				"config": {
+					"collector_cluster": "jaeger-collector.consul:9411",
+					"collector_endpoint": "/api/v2/spans"
+				}
+			}
+		}`,
+	}
+
+	got := parseTracingConfig(cfg)
+
+	require.Equal(t, "envoy.tracers.zipkin", got.Provider)
+	// CollectorAddress must be the cluster (the actual address), not the
+	// cluster concatenated with the unrelated HTTP endpoint path.
+	require.Equal(t, "jaeger-collector.consul:9411", got.CollectorAddress)
+}
+
+func TestParseTracingConfigEnvoyExtensionsFallback(t *testing.T) {
+	cfg := map[string]interface{}{
+		"envoy_extensions": []interface{}{
+			map[string]interface{}{
+				"name": "envoy.tracers.datadog",
+				"arguments": map[string]interface{}{
+					"config": map[string]interface{}{
+						"collector_address": "datadog-agent:8126",
+					},
+				},
+			},
+		},
+	}
+
+	require.Equal(t, Tracing{
+		Provider:         "envoy.tracers.datadog",
+		CollectorAddress: "datadog-agent:8126",
+	}, parseTracingConfig(cfg))
+}
+
+func TestParseAccessLogConfig(t *testing.T) {
+	cfg := map[string]interface{}{
+		"access_logs": map[string]interface{}{
+			"format":      "%ci - %ST",
+			"path":        "/var/log/haproxy.log",
+			"json_format": true,
+		},
+	}
+
+	require.Equal(t, AccessLog{
+		Format:      "%ci - %ST",
+		Destination: "/var/log/haproxy.log",
+		JSON:        true,
+	}, parseAccessLogConfig(cfg))
+}
+
+func TestParseAccessLogConfigSyslogTarget(t *testing.T) {
+	cfg := map[string]interface{}{
+		"access_logs": map[string]interface{}{
+			"syslog_target": "udp://127.0.0.1:514",
+		},
+	}
+
+	require.Equal(t, AccessLog{
+		Destination: "udp://127.0.0.1:514",
+	}, parseAccessLogConfig(cfg))
+}
+
+func TestParseAccessLogConfigMissing(t *testing.T) {
+	require.Equal(t, AccessLog{}, parseAccessLogConfig(map[string]interface{}{}))
+}
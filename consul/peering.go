@@ -0,0 +1,126 @@
+package consul
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// peerWatch tracks a single watchPeerTrustBundle goroutine. refs counts
+// how many live upstreams reference the peer, since several upstreams can
+// point at the same peer; the watch stops once the last one is removed.
+type peerWatch struct {
+	refs int
+	done bool
+}
+
+// acquirePeerTrustBundleWatch registers a reference on peer's watch,
+// creating it if necessary, and reports whether the caller is responsible
+// for actually running it. This must happen synchronously in the caller
+// (before anything is spawned with go) so a peer upstream that's added
+// and immediately removed can't race releasePeerTrustBundle: if the
+// refcount were only bumped once the watch goroutine got scheduled,
+// a fast-enough release would find no registration yet, return without
+// marking it done, and leak the goroutine that starts moments later.
+func (w *Watcher) acquirePeerTrustBundleWatch(peer string) (pw *peerWatch, start bool) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.peerWatches == nil {
+		w.peerWatches = map[string]*peerWatch{}
+	}
+	pw, ok := w.peerWatches[peer]
+	if !ok {
+		pw = &peerWatch{}
+		w.peerWatches[peer] = pw
+		start = true
+	}
+	pw.refs++
+
+	return pw, start
+}
+
+// watchPeerTrustBundle keeps w.peerTrustBundles[peer] in sync with the
+// peer's roots, analogous to watchCA but scoped to a single cluster peer.
+// Upstreams with DestinationPeer set must validate against these roots
+// instead of the local mesh's CA pool, since a peer's SPIFFE trust domain
+// differs from ours. Callers must have already registered pw via
+// acquirePeerTrustBundleWatch.
+func (w *Watcher) watchPeerTrustBundle(peer string, pw *peerWatch) {
+	w.log.Infof("consul: watching trust bundle for peer %s", peer)
+
+	var lastIndex uint64
+	for {
+		w.lock.Lock()
+		done := pw.done
+		w.lock.Unlock()
+		if done {
+			w.log.Infof("consul: stopping trust bundle watch for peer %s", peer)
+			return
+		}
+
+		p, meta, err := w.consul.Peerings().Read(context.Background(), peer, &api.QueryOptions{
+			WaitIndex: lastIndex,
+			WaitTime:  10 * time.Minute,
+		})
+		if err != nil {
+			w.log.Errorf("consul: error fetching trust bundle for peer %s: %s", peer, err)
+			time.Sleep(errorWaitTime)
+			lastIndex = 0
+			continue
+		}
+		if p == nil {
+			w.log.Warnf("consul: peer %s not found", peer)
+			time.Sleep(errorWaitTime)
+			continue
+		}
+
+		changed := lastIndex != meta.LastIndex
+		lastIndex = meta.LastIndex
+
+		if changed {
+			w.log.Infof("consul: trust bundle for peer %s changed", peer)
+
+			roots := make([][]byte, 0, len(p.PeerCAPems))
+			for _, pem := range p.PeerCAPems {
+				roots = append(roots, []byte(pem))
+			}
+
+			w.lock.Lock()
+			if pw.done {
+				// released while the query was in flight: don't bring back
+				// the bundle releasePeerTrustBundle discarded.
+				w.lock.Unlock()
+				continue
+			}
+			if w.peerTrustBundles == nil {
+				w.peerTrustBundles = map[string][][]byte{}
+			}
+			w.peerTrustBundles[peer] = roots
+			w.lock.Unlock()
+
+			w.notifyChanged()
+		}
+	}
+}
+
+// releasePeerTrustBundle drops one reference on peer's watch, stopping it
+// and discarding its cached trust bundle once the last upstream
+// referencing that peer has been removed.
+func (w *Watcher) releasePeerTrustBundle(peer string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	pw, ok := w.peerWatches[peer]
+	if !ok {
+		return
+	}
+
+	pw.refs--
+	if pw.refs <= 0 {
+		pw.done = true
+		delete(w.peerWatches, peer)
+		delete(w.peerTrustBundles, peer)
+	}
+}
@@ -0,0 +1,101 @@
+package consul
+
+import "crypto/x509"
+
+// streamBufferSize bounds how many pending deltas Endpoints/Secrets/Routes
+// will hold for a consumer that hasn't started draining yet. Emitting is
+// always best-effort on top of that: since no consumer is required to be
+// attached (the full Config on C remains authoritative), a full buffer
+// just drops the update rather than blocking the watch goroutine that
+// produced it.
+const streamBufferSize = 64
+
+// EndpointUpdate is a single upstream's node list, delivered whenever
+// health checks or membership change an upstream or one of its discovery
+// chain subsets. Consumers should apply these incrementally (e.g. via the
+// HAProxy Runtime API's "set server" family) instead of reloading.
+type EndpointUpdate struct {
+	Upstream string
+	// Subset is empty for the default (non-discovery-chain) node list.
+	Subset string
+	Nodes  []UpstreamNode
+}
+
+// SecretUpdate carries freshly rotated CA roots and/or leaf certificate
+// material. It is delivered on every CA or leaf cert rotation after the
+// watcher's initial ready signal, separately from structural config
+// changes so consumers can hot-swap certificates without reloading.
+type SecretUpdate struct {
+	CAsPool *x509.CertPool
+	CAs     [][]byte
+	Cert    []byte
+	Key     []byte
+}
+
+// RouteUpdate carries a single upstream's recompiled discovery chain
+// routing table, delivered whenever the service-router/service-splitter/
+// service-resolver config entries backing it change.
+type RouteUpdate struct {
+	Upstream string
+	Routes   []Route
+}
+
+// Endpoints streams per-upstream node list deltas. Unlike C, it never
+// carries a full Config: consumers apply these against their existing
+// backend state instead of regenerating it. A full Config is still sent
+// on C for every change, so Endpoints is safe to leave undrained.
+func (w *Watcher) Endpoints() <-chan EndpointUpdate {
+	return w.endpoints
+}
+
+// Secrets streams CA/leaf cert rotations, separately from C so that
+// certificate renewal never forces a full config reload on its own. A
+// full Config is still sent on C for every rotation, so Secrets is safe
+// to leave undrained.
+func (w *Watcher) Secrets() <-chan SecretUpdate {
+	return w.secrets
+}
+
+// Routes streams discovery chain recompilations, separately from C so
+// that routing table changes can be applied without touching endpoints. A
+// full Config is still sent on C for every change, so Routes is safe to
+// leave undrained.
+func (w *Watcher) Routes() <-chan RouteUpdate {
+	return w.routes
+}
+
+// emitEndpoint is best-effort: it never blocks the calling watch
+// goroutine, so an unattached or slow consumer cannot stall upstream
+// health propagation. notifyChanged must still be called by the caller to
+// keep the full Config on C authoritative.
+func (w *Watcher) emitEndpoint(upstream, subset string, nodes []UpstreamNode) {
+	select {
+	case w.endpoints <- EndpointUpdate{Upstream: upstream, Subset: subset, Nodes: nodes}:
+	default:
+	}
+}
+
+func (w *Watcher) emitRoute(upstream string, routes []Route) {
+	select {
+	case w.routes <- RouteUpdate{Upstream: upstream, Routes: routes}:
+	default:
+	}
+}
+
+func (w *Watcher) emitSecret() {
+	w.lock.Lock()
+	update := SecretUpdate{
+		CAsPool: w.certCAPool,
+		CAs:     w.certCAs,
+	}
+	if w.leaf != nil {
+		update.Cert = w.leaf.Cert
+		update.Key = w.leaf.Key
+	}
+	w.lock.Unlock()
+
+	select {
+	case w.secrets <- update:
+	default:
+	}
+}
@@ -25,9 +25,18 @@ type upstream struct {
 	LocalBindPort    int
 	Name             string
 	Datacenter       string
+	Peer             string
 	Protocol         string
 	Nodes            []*api.ServiceEntry
 
+	// Routes, subsets and subsetWeights are populated for "http" protocol
+	// upstreams from the compiled discovery chain. subsets is keyed by
+	// subset name (empty string for the default subset).
+	Routes        []Route
+	subsets       map[string][]*api.ServiceEntry
+	subsetWeights map[string]float32
+	subsetWatches map[string]*subsetWatch
+
 	done bool
 }
 
@@ -39,6 +48,7 @@ type downstream struct {
 	TargetPort        int
 	EnableForwardFor  bool
 	AppNameHeaderName string
+	Tracing           Tracing
 }
 
 type certLeaf struct {
@@ -58,14 +68,24 @@ type Watcher struct {
 	lock  sync.Mutex
 	ready sync.WaitGroup
 
-	upstreams  map[string]*upstream
-	downstream downstream
-	certCAs    [][]byte
-	certCAPool *x509.CertPool
-	leaf       *certLeaf
-
-	update chan struct{}
-	log    Logger
+	upstreams        map[string]*upstream
+	downstream       downstream
+	certCAs          [][]byte
+	certCAPool       *x509.CertPool
+	leaf             *certLeaf
+	peerTrustBundles map[string][][]byte
+	peerWatches      map[string]*peerWatch
+	intentions       []IntentionRule
+	accessLog        AccessLog
+	prometheus       PrometheusConfig
+	metrics          Metrics
+
+	update    chan struct{}
+	endpoints chan EndpointUpdate
+	secrets   chan SecretUpdate
+	routes    chan RouteUpdate
+
+	log Logger
 }
 
 // New builds a new watcher
@@ -77,6 +97,9 @@ func New(service string, consul *api.Client, log Logger) *Watcher {
 		C:         make(chan Config),
 		upstreams: make(map[string]*upstream),
 		update:    make(chan struct{}, 1),
+		endpoints: make(chan EndpointUpdate, streamBufferSize),
+		secrets:   make(chan SecretUpdate, streamBufferSize),
+		routes:    make(chan RouteUpdate, streamBufferSize),
 		log:       log,
 	}
 }
@@ -94,7 +117,7 @@ func (w *Watcher) Run() error {
 
 	w.serviceName = svc.Service
 
-	w.ready.Add(4)
+	w.ready.Add(5)
 
 	go w.watchCA()
 	go w.watchLeaf()
@@ -105,6 +128,7 @@ func (w *Watcher) Run() error {
 			w.ready.Done()
 		}
 	})
+	go w.watchIntentions()
 
 	w.ready.Wait()
 
@@ -136,6 +160,10 @@ func (w *Watcher) handleProxyChange(first bool, srv *api.AgentService) {
 		if a, ok := srv.Proxy.Config["appname_header"].(string); ok {
 			w.downstream.AppNameHeaderName = a
 		}
+
+		w.downstream.Tracing = parseTracingConfig(srv.Proxy.Config)
+		w.accessLog = parseAccessLogConfig(srv.Proxy.Config)
+		w.prometheus = parsePrometheusConfig(srv.Proxy.Config)
 	}
 
 	keep := make(map[string]bool)
@@ -143,11 +171,20 @@ func (w *Watcher) handleProxyChange(first bool, srv *api.AgentService) {
 	if srv.Proxy != nil {
 		for _, up := range srv.Proxy.Upstreams {
 			name := fmt.Sprintf("%s_%s", up.DestinationType, up.DestinationName)
+			if up.DestinationPeer != "" {
+				name = fmt.Sprintf("%s_%s_%s", up.DestinationType, up.DestinationName, up.DestinationPeer)
+			}
 			keep[name] = true
 			w.lock.Lock()
 			_, ok := w.upstreams[name]
 			w.lock.Unlock()
 			if !ok {
+				if up.DestinationPeer != "" {
+					pw, start := w.acquirePeerTrustBundleWatch(up.DestinationPeer)
+					if start {
+						go w.watchPeerTrustBundle(up.DestinationPeer, pw)
+					}
+				}
 				switch up.DestinationType {
 				case api.UpstreamDestTypePreparedQuery:
 					w.startUpstreamPreparedQuery(up, name)
@@ -177,6 +214,7 @@ func (w *Watcher) startUpstreamService(up api.Upstream, name string) {
 		LocalBindPort:    up.LocalBindPort,
 		Name:             name,
 		Datacenter:       up.Datacenter,
+		Peer:             up.DestinationPeer,
 	}
 
 	if p, ok := up.Config["protocol"].(string); ok {
@@ -187,19 +225,29 @@ func (w *Watcher) startUpstreamService(up api.Upstream, name string) {
 	w.upstreams[name] = u
 	w.lock.Unlock()
 
+	if u.Protocol == "http" {
+		w.startDiscoveryChain(up, name, u)
+	}
+
 	go func() {
 		index := uint64(0)
 		for {
 			if u.done {
 				return
 			}
-			nodes, meta, err := w.consul.Health().Connect(up.DestinationName, "", true, &api.QueryOptions{
-				Datacenter: up.Datacenter,
-				WaitTime:   10 * time.Minute,
-				WaitIndex:  index,
-			})
+			qOpts := &api.QueryOptions{
+				WaitTime:  10 * time.Minute,
+				WaitIndex: index,
+			}
+			if up.DestinationPeer != "" {
+				qOpts.Peer = up.DestinationPeer
+			} else {
+				qOpts.Datacenter = up.Datacenter
+			}
+			nodes, meta, err := w.consul.Health().Connect(up.DestinationName, "", true, qOpts)
 			if err != nil {
 				w.log.Errorf("consul: error fetching service definition for service %s: %s", up.DestinationName, err)
+				w.incError("upstream")
 				time.Sleep(errorWaitTime)
 				index = 0
 				continue
@@ -208,9 +256,12 @@ func (w *Watcher) startUpstreamService(up api.Upstream, name string) {
 			index = meta.LastIndex
 
 			if changed {
+				healthy := healthyNodes(nodes)
 				w.lock.Lock()
 				u.Nodes = nodes
 				w.lock.Unlock()
+				w.setUpstreamNodes(name, len(healthy))
+				w.emitEndpoint(name, "", healthy)
 				w.notifyChanged()
 			}
 		}
@@ -277,6 +328,7 @@ func (w *Watcher) startUpstreamPreparedQuery(up api.Upstream, name string) {
 				w.lock.Lock()
 				u.Nodes = nodesP
 				w.lock.Unlock()
+				w.emitEndpoint(name, "", healthyNodes(nodesP))
 				w.notifyChanged()
 				last = nodesP
 			}
@@ -290,9 +342,18 @@ func (w *Watcher) removeUpstream(name string) {
 	w.log.Infof("consul: removing upstream for service %s", name)
 
 	w.lock.Lock()
-	w.upstreams[name].done = true
+	u := w.upstreams[name]
+	u.done = true
+	for _, sw := range u.subsetWatches {
+		sw.done = true
+	}
+	peer := u.Peer
 	delete(w.upstreams, name)
 	w.lock.Unlock()
+
+	if peer != "" {
+		w.releasePeerTrustBundle(peer)
+	}
 }
 
 func (w *Watcher) watchLeaf() {
@@ -307,6 +368,7 @@ func (w *Watcher) watchLeaf() {
 		})
 		if err != nil {
 			w.log.Errorf("consul error fetching leaf cert for service %s: %s", w.serviceName, err)
+			w.incError("leaf")
 			time.Sleep(errorWaitTime)
 			lastIndex = 0
 			continue
@@ -324,6 +386,12 @@ func (w *Watcher) watchLeaf() {
 			w.leaf.Cert = []byte(cert.CertPEM)
 			w.leaf.Key = []byte(cert.PrivateKeyPEM)
 			w.lock.Unlock()
+
+			w.setLeafCertExpiry(time.Until(cert.ValidBefore).Seconds())
+
+			if !first {
+				w.emitSecret()
+			}
 			w.notifyChanged()
 		}
 
@@ -377,6 +445,7 @@ func (w *Watcher) watchCA() {
 		})
 		if err != nil {
 			w.log.Errorf("consul: error fetching cas: %s", err)
+			w.incError("ca")
 			time.Sleep(errorWaitTime)
 			lastIndex = 0
 			continue
@@ -398,6 +467,11 @@ func (w *Watcher) watchCA() {
 				}
 			}
 			w.lock.Unlock()
+
+			if !first {
+				w.incCARootRotation()
+				w.emitSecret()
+			}
 			w.notifyChanged()
 		}
 
@@ -411,6 +485,7 @@ func (w *Watcher) watchCA() {
 
 func (w *Watcher) genCfg() Config {
 	w.log.Debugf("generating configuration for service %s[%s]...", w.serviceName, w.service)
+	w.incConfigReload()
 	w.lock.Lock()
 	serviceInstancesAlive := 0
 	serviceInstancesTotal := 0
@@ -424,6 +499,8 @@ func (w *Watcher) genCfg() Config {
 		ServiceName: w.serviceName,
 		ServiceID:   w.service,
 		CAsPool:     w.certCAPool,
+		Intentions:  w.intentions,
+		AccessLog:   w.accessLog,
 		Downstream: Downstream{
 			LocalBindAddress:  w.downstream.LocalBindAddress,
 			LocalBindPort:     w.downstream.LocalBindPort,
@@ -432,6 +509,7 @@ func (w *Watcher) genCfg() Config {
 			Protocol:          w.downstream.Protocol,
 			EnableForwardFor:  w.downstream.EnableForwardFor,
 			AppNameHeaderName: w.downstream.AppNameHeaderName,
+			Tracing:           w.downstream.Tracing,
 
 			TLS: TLS{
 				CAs:  w.certCAs,
@@ -442,17 +520,43 @@ func (w *Watcher) genCfg() Config {
 	}
 
 	for _, up := range w.upstreams {
+		// A peer upstream's trust bundle is fetched asynchronously by
+		// watchPeerTrustBundle and may not have arrived yet. Withhold the
+		// upstream entirely rather than shipping it with an empty CAs
+		// pool: watchPeerTrustBundle calls notifyChanged once the bundle
+		// lands, so genCfg runs again and picks it up.
+		if up.Peer != "" && len(w.peerTrustBundles[up.Peer]) == 0 {
+			w.log.Debugf("consul: withholding upstream %s pending trust bundle for peer %s", up.Name, up.Peer)
+			continue
+		}
+
 		upstream := Upstream{
 			Name:             up.Name,
 			LocalBindAddress: up.LocalBindAddress,
 			LocalBindPort:    up.LocalBindPort,
 			Protocol:         up.Protocol,
+			Routes:           up.Routes,
 			TLS: TLS{
-				CAs:  w.certCAs,
+				CAs:  w.peerCAs(up.Peer),
 				Cert: w.leaf.Cert,
 				Key:  w.leaf.Key,
 			},
 		}
+
+		// Subsets only matter for routing Route.Destination.Subset lookups.
+		// A chain without a service-router config entry has no Routes, so
+		// its subsets are not shipped and Nodes alone is used; this holds
+		// even when a service-splitter splits such a chain.
+		if len(up.Routes) > 0 {
+			for name, nodes := range up.subsets {
+				upstream.Subsets = append(upstream.Subsets, Subset{
+					Name:   name,
+					Weight: up.subsetWeights[name],
+					Nodes:  healthyNodes(nodes),
+				})
+			}
+		}
+
 		for _, s := range up.Nodes {
 			serviceInstancesTotal++
 
@@ -495,6 +599,54 @@ func (w *Watcher) genCfg() Config {
 	return config
 }
 
+// peerCAs returns the trust bundle to validate an upstream's leaf certs
+// against: the peer's own roots for a peer-cluster upstream, or the local
+// mesh CA pool otherwise. Callers must already hold w.lock.
+func (w *Watcher) peerCAs(peer string) [][]byte {
+	if peer == "" {
+		return w.certCAs
+	}
+	return w.peerTrustBundles[peer]
+}
+
+// healthyNodes filters out failing instances and converts the rest to
+// UpstreamNodes, applying the same passing/warning weight rules as genCfg.
+func healthyNodes(nodes []*api.ServiceEntry) []UpstreamNode {
+	var out []UpstreamNode
+	for _, s := range nodes {
+		weight := 1
+		switch s.Checks.AggregatedStatus() {
+		case api.HealthPassing:
+			weight = s.Service.Weights.Passing
+		case api.HealthWarning:
+			weight = s.Service.Weights.Warning
+		default:
+			continue
+		}
+		if weight == 0 {
+			continue
+		}
+
+		address := s.Service.Address
+		if address == "" {
+			address = s.Node.Address
+		}
+
+		out = append(out, UpstreamNode{
+			Name:    s.Node.Node,
+			Address: address,
+			Port:    s.Service.Port,
+			Weight:  weight,
+		})
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Name < out[j].Name
+	})
+
+	return out
+}
+
 func (w *Watcher) notifyChanged() {
 	select {
 	case w.update <- struct{}{}:
@@ -6,19 +6,51 @@ import (
 	"sync"
 	"sync/atomic"
 	"syscall"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 )
 
 type Shutdown struct {
 	sync.WaitGroup
-	Stop    chan struct{}
+	Stop chan struct{}
+
+	// Draining is closed as soon as a shutdown starts (SIGINT, SIGTERM or
+	// a call to Shutdown), before Stop, on a Shutdown built by
+	// NewShutdownWithDrain. It lets a consumer (e.g. haproxy, via the
+	// runtime API) set its servers to "state drain" and stop accepting new
+	// downstream connections while in-flight requests finish. A Shutdown
+	// built by NewShutdown never closes it.
+	Draining chan struct{}
+
+	drainTimeout  time.Duration
+	markUnhealthy func()
+
 	stopped uint32
 }
 
+// NewShutdown builds a Shutdown that closes Stop immediately on signal,
+// with no drain phase.
 func NewShutdown() *Shutdown {
+	return newShutdown(0, nil)
+}
+
+// NewShutdownWithDrain builds a Shutdown that, on signal, first calls
+// MarkUnhealthy, closes Draining, then waits up to drainTimeout for the
+// WaitGroup to drop to zero before closing Stop to force-terminate. This
+// matches the connection-draining behavior needed for zero-downtime
+// rolling deploys: in-flight requests get a chance to finish while new
+// ones are routed to other instances first.
+func NewShutdownWithDrain(drainTimeout time.Duration, markUnhealthy func()) *Shutdown {
+	return newShutdown(drainTimeout, markUnhealthy)
+}
+
+func newShutdown(drainTimeout time.Duration, markUnhealthy func()) *Shutdown {
 	sd := &Shutdown{
-		Stop: make(chan struct{}),
+		Stop:          make(chan struct{}),
+		Draining:      make(chan struct{}),
+		drainTimeout:  drainTimeout,
+		markUnhealthy: markUnhealthy,
 	}
 
 	sigs := make(chan os.Signal, 1)
@@ -39,10 +71,47 @@ func NewShutdown() *Shutdown {
 	return sd
 }
 
+// MarkUnhealthy runs the callback passed to NewShutdownWithDrain, if any,
+// so the instance stops being reported as healthy (e.g. via a TTL check)
+// before connections start draining.
+func (h *Shutdown) MarkUnhealthy() {
+	if h.markUnhealthy != nil {
+		h.markUnhealthy()
+	}
+}
+
 func (h *Shutdown) Shutdown(reason string) {
 	if atomic.SwapUint32(&h.stopped, 1) > 0 {
 		return
 	}
 	log.Infof("Shutting down because %s...", reason)
+
+	if h.drainTimeout == 0 {
+		close(h.Stop)
+		return
+	}
+
+	go h.drain()
+}
+
+func (h *Shutdown) drain() {
+	h.MarkUnhealthy()
+
+	log.Infof("Draining for up to %s...", h.drainTimeout)
+	close(h.Draining)
+
+	done := make(chan struct{})
+	go func() {
+		h.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Infof("Drain complete")
+	case <-time.After(h.drainTimeout):
+		log.Warnf("Drain deadline of %s exceeded, forcing shutdown", h.drainTimeout)
+	}
+
 	close(h.Stop)
 }
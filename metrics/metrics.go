@@ -0,0 +1,231 @@
+// Package metrics exposes a Prometheus scrape endpoint for the sidecar,
+// covering both its own watcher state and haproxy's own stats socket
+// converted to Prometheus exposition format, so operators get a single
+// uniform scrape target regardless of proxy implementation.
+package metrics
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Collector holds the Prometheus metrics this module reports and
+// implements consul.Metrics so a *Watcher can report into it directly.
+type Collector struct {
+	registry *prometheus.Registry
+
+	configReloads   prometheus.Counter
+	upstreamNodes   *prometheus.GaugeVec
+	leafCertExpiry  prometheus.Gauge
+	caRootRotations prometheus.Counter
+	watcherErrors   *prometheus.CounterVec
+}
+
+// NewCollector builds a Collector and registers its metrics under the
+// "haproxy_consul_connect" namespace.
+func NewCollector() *Collector {
+	registry := prometheus.NewRegistry()
+
+	c := &Collector{
+		registry: registry,
+		configReloads: promauto.With(registry).NewCounter(prometheus.CounterOpts{
+			Namespace: "haproxy_consul_connect",
+			Name:      "config_reloads_total",
+			Help:      "Number of times the haproxy configuration was fully regenerated.",
+		}),
+		upstreamNodes: promauto.With(registry).NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "haproxy_consul_connect",
+			Name:      "upstream_nodes",
+			Help:      "Number of healthy nodes known for an upstream.",
+		}, []string{"upstream"}),
+		leafCertExpiry: promauto.With(registry).NewGauge(prometheus.GaugeOpts{
+			Namespace: "haproxy_consul_connect",
+			Name:      "leaf_cert_expiry_seconds",
+			Help:      "Seconds until the current leaf certificate expires.",
+		}),
+		caRootRotations: promauto.With(registry).NewCounter(prometheus.CounterOpts{
+			Namespace: "haproxy_consul_connect",
+			Name:      "ca_root_rotations_total",
+			Help:      "Number of times the CA root set changed.",
+		}),
+		watcherErrors: promauto.With(registry).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "haproxy_consul_connect",
+			Name:      "watcher_errors_total",
+			Help:      "Number of errors encountered watching consul, by subsystem.",
+		}, []string{"subsystem"}),
+	}
+
+	return c
+}
+
+func (c *Collector) IncConfigReload() {
+	c.configReloads.Inc()
+}
+
+func (c *Collector) SetUpstreamNodes(upstream string, n int) {
+	c.upstreamNodes.WithLabelValues(upstream).Set(float64(n))
+}
+
+func (c *Collector) SetLeafCertExpiry(seconds float64) {
+	c.leafCertExpiry.Set(seconds)
+}
+
+func (c *Collector) IncCARootRotation() {
+	c.caRootRotations.Inc()
+}
+
+func (c *Collector) IncError(subsystem string) {
+	c.watcherErrors.WithLabelValues(subsystem).Inc()
+}
+
+// Config configures the metrics HTTP server.
+type Config struct {
+	BindAddr string
+
+	// CertFile, when set, causes the server to serve TLS with
+	// CertFile/KeyFile. CAPool, when also non-nil, makes it require client
+	// certs signed by CAPool, reusing the same mesh CA the watcher already
+	// builds (or a dedicated prometheus_ca_file if the operator sets one).
+	CAPool   *x509.CertPool
+	CertFile string
+	KeyFile  string
+
+	// HAProxyStatsSocket, when set, is scraped and exposed as additional
+	// metrics alongside this module's own.
+	HAProxyStatsSocket string
+}
+
+// Server serves /metrics for a Collector, merging in an optional haproxy
+// stats socket scrape.
+type Server struct {
+	config Config
+	srv    *http.Server
+}
+
+// NewServer builds a metrics HTTP server. Call ListenAndServe to start it.
+// The sidecar's entrypoint is responsible for constructing a Collector,
+// passing it to Watcher.SetMetrics before Run, and running the Server
+// returned here (typically in its own goroutine) — this package only
+// provides the pieces, it never starts itself.
+func NewServer(cfg Config, collector *Collector) *Server {
+	// Compression stays off: the haproxy stats lines are written as plain
+	// text after the Collector output, which a gzipped body cannot carry.
+	promHandler := promhttp.HandlerFor(collector.registry, promhttp.HandlerOpts{
+		DisableCompression: true,
+	})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		promHandler.ServeHTTP(w, r)
+		if cfg.HAProxyStatsSocket != "" {
+			// Best-effort: a scrape failure here shouldn't blank out the
+			// Collector metrics already written above.
+			scrapeHAProxyStats(cfg.HAProxyStatsSocket, w)
+		}
+	})
+
+	return &Server{
+		config: cfg,
+		srv: &http.Server{
+			Addr:    cfg.BindAddr,
+			Handler: mux,
+		},
+	}
+}
+
+// ListenAndServe starts the metrics server, blocking until it stops. If
+// the server was configured with a CertFile, it serves TLS, and if CAPool
+// is also set it requires and verifies client certificates against it.
+func (s *Server) ListenAndServe() error {
+	if s.config.CertFile == "" {
+		return s.srv.ListenAndServe()
+	}
+
+	s.srv.TLSConfig = &tls.Config{
+		ClientCAs: s.config.CAPool,
+		ClientAuth: func() tls.ClientAuthType {
+			if s.config.CAPool != nil {
+				return tls.RequireAndVerifyClientCert
+			}
+			return tls.NoClientCert
+		}(),
+	}
+
+	return s.srv.ListenAndServeTLS(s.config.CertFile, s.config.KeyFile)
+}
+
+// Close shuts down the metrics server.
+func (s *Server) Close() error {
+	return s.srv.Close()
+}
+
+// scrapeHAProxyStats reads "show stat" off the haproxy stats socket and
+// appends it to out as Prometheus exposition lines, one haproxy_stat_<field>
+// gauge per numeric CSV column, labeled by proxy/server name, so a single
+// Prometheus scrape of /metrics covers haproxy's own stats too.
+func scrapeHAProxyStats(socketPath string, out io.Writer) error {
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("show stat\n")); err != nil {
+		return err
+	}
+
+	var header []string
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+
+		fields := strings.Split(line, ",")
+		if header == nil {
+			fields[0] = strings.TrimPrefix(fields[0], "# ")
+			header = fields
+			continue
+		}
+
+		writeHAProxyStatRow(out, header, fields)
+	}
+
+	return scanner.Err()
+}
+
+// writeHAProxyStatRow converts a single "show stat" row into one gauge
+// line per numeric column, keyed by that column's header name and labeled
+// with the row's pxname/svname (always the first two CSV columns).
+func writeHAProxyStatRow(out io.Writer, header, fields []string) {
+	if len(fields) < 2 {
+		return
+	}
+	pxname, svname := fields[0], fields[1]
+
+	for i := 2; i < len(header) && i < len(fields); i++ {
+		value := fields[i]
+		if value == "" {
+			continue
+		}
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			continue
+		}
+
+		fmt.Fprintf(out, "haproxy_stat_%s{pxname=%q,svname=%q} %s\n",
+			header[i], pxname, svname, strconv.FormatFloat(f, 'g', -1, 64))
+	}
+}
@@ -0,0 +1,25 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteHAProxyStatRow(t *testing.T) {
+	header := []string{"pxname", "svname", "scur", "status"}
+	fields := []string{"web", "srv1", "3", "UP"}
+
+	var buf bytes.Buffer
+	writeHAProxyStatRow(&buf, header, fields)
+
+	// "status" is non-numeric and must be skipped; only scur is emitted.
+	require.Equal(t, `haproxy_stat_scur{pxname="web",svname="srv1"} 3`+"\n", buf.String())
+}
+
+func TestWriteHAProxyStatRowShortRow(t *testing.T) {
+	var buf bytes.Buffer
+	writeHAProxyStatRow(&buf, []string{"pxname", "svname", "scur"}, []string{"web"})
+	require.Empty(t, buf.String())
+}